@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultCompressMinBytes is used when config.compress.minBytes is unset,
+// so responses smaller than a single TCP segment aren't bothered with.
+const defaultCompressMinBytes = 1024
+
+// compress transparently gzips (or deflates) the response body when the
+// client advertises support for it via Accept-Encoding, the body clears
+// the configured minimum size, and the Content-Type looks compressible.
+// It must sit right before metrics in the middleware chain so that the
+// byte counts recorded there reflect what actually went out on the wire.
+func (app *application) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedCompressEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		minBytes := app.config.compress.minBytes
+		if minBytes <= 0 {
+			minBytes = defaultCompressMinBytes
+		}
+
+		cw := &compressResponseWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minBytes:       minBytes,
+		}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedCompressEncoding picks gzip over deflate when both are offered,
+// since gzip has the wider client support.
+func acceptedCompressEncoding(acceptEncoding string) string {
+	switch {
+	case strings.Contains(acceptEncoding, "gzip"):
+		return "gzip"
+	case strings.Contains(acceptEncoding, "deflate"):
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+func isCompressibleContentType(contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.TrimSpace(contentType)
+
+	return contentType == "application/json" || strings.HasPrefix(contentType, "text/")
+}
+
+// compressResponseWriter buffers the start of a response so it can decide,
+// once it knows the Content-Type and has seen at least minBytes, whether
+// compressing is worthwhile. Bodies that never reach minBytes are flushed
+// uncompressed when the handler returns.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding   string
+	minBytes   int
+	buf        bytes.Buffer
+	compressor io.WriteCloser
+	statusCode int
+	decided    bool
+	compress   bool
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.statusCode == 0 {
+		cw.statusCode = statusCode
+	}
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		if cw.compress {
+			return cw.compressor.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf.Write(b)
+	if cw.buf.Len() < cw.minBytes {
+		return len(b), nil
+	}
+
+	cw.decide()
+	return len(b), nil
+}
+
+func (cw *compressResponseWriter) decide() {
+	cw.decided = true
+
+	if cw.statusCode == 0 {
+		cw.statusCode = http.StatusOK
+	}
+
+	// Don't double-compress a body that's already encoded upstream.
+	cw.compress = cw.Header().Get("Content-Encoding") == "" &&
+		isCompressibleContentType(cw.Header().Get("Content-Type")) &&
+		cw.buf.Len() >= cw.minBytes
+
+	if !cw.compress {
+		cw.ResponseWriter.WriteHeader(cw.statusCode)
+		cw.ResponseWriter.Write(cw.buf.Bytes())
+		return
+	}
+
+	cw.Header().Set("Content-Encoding", cw.encoding)
+	cw.Header().Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	if cw.encoding == "deflate" {
+		fw, _ := flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		cw.compressor = fw
+	} else {
+		cw.compressor = gzip.NewWriter(cw.ResponseWriter)
+	}
+	cw.compressor.Write(cw.buf.Bytes())
+}
+
+// Close flushes any buffered, not-yet-decided body and closes the
+// underlying compressor (if one was opened). Safe to call more than once.
+func (cw *compressResponseWriter) Close() error {
+	if !cw.decided {
+		cw.decide()
+	}
+	if cw.compressor != nil {
+		err := cw.compressor.Close()
+		cw.compressor = nil
+		return err
+	}
+	return nil
+}
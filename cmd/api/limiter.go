@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// Limiter decides whether a request identified by key is allowed right now,
+// given rps (requests per second) and burst. memoryLimiter keeps this
+// decision local to the process, which breaks down the moment the API runs
+// as more than one instance; redisLimiter shares the same token bucket
+// across every instance pointed at one Redis so they enforce the limit
+// together. Selected via -limiter-backend=memory|redis.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rps float64, burst int) (bool, error)
+}
+
+// memoryLimiter is the original in-process limiter: one token bucket per
+// key, garbage collected after a few minutes of inactivity.
+type memoryLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*memoryLimiterClient
+}
+
+type memoryLimiterClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	l := &memoryLimiter{clients: make(map[string]*memoryLimiterClient)}
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			l.mu.Lock()
+			for key, c := range l.clients {
+				if time.Since(c.lastSeen) > 3*time.Minute {
+					delete(l.clients, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}()
+
+	return l
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, rps float64, burst int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, found := l.clients[key]
+	if !found {
+		c = &memoryLimiterClient{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = time.Now()
+
+	return c.limiter.Allow(), nil
+}
+
+// redisLimiterScript implements the token-bucket algorithm atomically, so
+// concurrent instances sharing one Redis never race on the same bucket.
+const redisLimiterScript = `
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens') or ARGV[1])
+local ts = tonumber(redis.call('HGET', KEYS[1], 'ts') or ARGV[2])
+local refill = (tonumber(ARGV[2]) - ts) * tonumber(ARGV[3])
+tokens = math.min(tonumber(ARGV[1]), tokens + refill)
+if tokens < 1 then
+	return 0
+else
+	redis.call('HMSET', KEYS[1], 'tokens', tokens - 1, 'ts', ARGV[2])
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+	return 1
+end
+`
+
+type redisLimiter struct {
+	client redis.UniversalClient
+	script *redis.Script
+}
+
+func newRedisLimiter(addr string) *redisLimiter {
+	return &redisLimiter{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+		script: redis.NewScript(redisLimiterScript),
+	}
+}
+
+// Allow spends one token from the bucket identified by key. capacity is
+// burst, ts is the current time in milliseconds (so the refill rate passed
+// to the script is rps converted to tokens per millisecond), and the bucket expires
+// after twice the time it'd take to refill from empty so idle keys don't
+// linger in Redis forever.
+func (l *redisLimiter) Allow(ctx context.Context, key string, rps float64, burst int) (bool, error) {
+	now := time.Now().UnixMilli()
+
+	ttlMillis := int64(2000 * float64(burst) / rps)
+	if ttlMillis <= 0 {
+		ttlMillis = 1000
+	}
+
+	result, err := l.script.Run(ctx, l.client, []string{"ratelimit:" + key}, burst, now, rps/1000, ttlMillis).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return result == 1, nil
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"compress/gzip"
 	"expvar"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -10,6 +12,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
 	"greenlight.bcc/internal/data"
 )
 
@@ -96,6 +103,100 @@ func Test_metrics(t *testing.T) {
 	}
 }
 
+func Test_compress(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.compress.minBytes = 10
+
+	body := strings.Repeat("Cheese and bread. ", 100)
+
+	handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+
+	t.Run("client accepts gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("Content-Encoding header not set correctly: got %q", rr.Header().Get("Content-Encoding"))
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Vary header not set correctly: got %q", rr.Header().Get("Vary"))
+		}
+
+		gr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("response body was not valid gzip: %v", err)
+		}
+		defer gr.Close()
+
+		decompressed, err := io.ReadAll(gr)
+		if err != nil {
+			t.Fatalf("failed to decompress response body: %v", err)
+		}
+		if string(decompressed) != body {
+			t.Errorf("decompressed body does not match: got %q want %q", decompressed, body)
+		}
+	})
+
+	t.Run("client does not accept gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Content-Encoding header should not be set, got %q", rr.Header().Get("Content-Encoding"))
+		}
+		if rr.Body.String() != body {
+			t.Errorf("body should be passed through unmodified: got %q want %q", rr.Body.String(), body)
+		}
+	})
+}
+
+func Test_metrics_prometheus(t *testing.T) {
+	app := newTestApplication(t)
+
+	handler := app.metrics(withRoutePattern("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "Cheese and bread")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	labels := prometheus.Labels{"method": http.MethodGet, "route": "/foo", "code": strconv.Itoa(rr.Code)}
+
+	counterBefore := testutil.ToFloat64(promRequestsTotal.With(labels))
+	if counterBefore < 1 {
+		t.Errorf("expected http_requests_total{method=GET,route=/foo,code=200} to have incremented, got %v", counterBefore)
+	}
+
+	metricFamilies, err := promRegistry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather prometheus metrics: %v", err)
+	}
+
+	var sawHistogram bool
+	for _, mf := range metricFamilies {
+		if mf.GetName() == "http_request_duration_seconds" {
+			for _, m := range mf.GetMetric() {
+				if m.GetHistogram().GetSampleCount() > 0 {
+					sawHistogram = true
+				}
+			}
+		}
+	}
+	if !sawHistogram {
+		t.Error("expected http_request_duration_seconds histogram to have observed a sample")
+	}
+}
+
 func Test_requireAuthenticatedUser(t *testing.T) {
 	app := newTestApplication(t)
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
@@ -216,12 +317,82 @@ func Test_authenticate(t *testing.T) {
 	}
 }
 
+func Test_authenticateJWT(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.jwt.secret = "test-secret"
+	app.config.jwt.issuer = "greenlight.bcc"
+	app.config.jwt.audience = "greenlight.bcc-clients"
+
+	signToken := func(secret, issuer, audience string, expiresAt time.Time) string {
+		claims := jwtClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "1",
+				Issuer:    issuer,
+				Audience:  jwt.ClaimStrings{audience},
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("failed to sign test token: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "successful authentication",
+			token:      signToken("test-secret", "greenlight.bcc", "greenlight.bcc-clients", time.Now().Add(time.Hour)),
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "expired token",
+			token:      signToken("test-secret", "greenlight.bcc", "greenlight.bcc-clients", time.Now().Add(-time.Hour)),
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong audience",
+			token:      signToken("test-secret", "greenlight.bcc", "somebody-else", time.Now().Add(time.Hour)),
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong signature",
+			token:      signToken("wrong-secret", "greenlight.bcc", "greenlight.bcc-clients", time.Now().Add(time.Hour)),
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := app.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.statusCode {
+				t.Errorf("Expected status code %d, but got %d", tt.statusCode, rr.Code)
+			}
+		})
+	}
+}
+
 func Test_rateLimit(t *testing.T) {
 	app := newTestApplication(t)
 
 	app.config.limiter.enabled = true
 	app.config.limiter.rps = 1
 	app.config.limiter.burst = 2
+	app.config.limiter.backend = newMemoryLimiter()
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.RemoteAddr = "127.0.0.1:12345"
@@ -253,6 +424,58 @@ func Test_rateLimit(t *testing.T) {
 	}
 }
 
+func Test_rateLimit_redisBackendCrossInstance(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	backend := newRedisLimiter(mr.Addr())
+
+	newLimitedApp := func() *application {
+		app := newTestApplication(t)
+		app.config.limiter.enabled = true
+		app.config.limiter.rps = 1
+		app.config.limiter.burst = 2
+		app.config.limiter.backend = backend
+		return app
+	}
+
+	// Two separate application instances, sharing only the Redis backend,
+	// must enforce the limit together rather than each getting their own
+	// allowance the way two in-process memoryLimiters would.
+	instanceA := newLimitedApp()
+	instanceB := newLimitedApp()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mwA := instanceA.rateLimit(handler)
+	mwB := instanceB.rateLimit(handler)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+
+	results := []int{}
+	for i, mw := range []http.Handler{mwA, mwB, mwA, mwB} {
+		res := httptest.NewRecorder()
+		mw.ServeHTTP(res, req)
+		results = append(results, res.Result().StatusCode)
+		_ = i
+	}
+
+	// burst is 2, so only the first two requests across both instances
+	// should succeed; the third and fourth must be rejected together.
+	if results[0] != http.StatusOK || results[1] != http.StatusOK {
+		t.Errorf("expected the first 2 cross-instance requests to succeed, got %v", results)
+	}
+	if results[2] != http.StatusTooManyRequests || results[3] != http.StatusTooManyRequests {
+		t.Errorf("expected requests 3 and 4 to be rejected once the shared bucket is exhausted, got %v", results)
+	}
+}
+
 func Test_recoverPanic(t *testing.T) {
 	app := newTestApplication(t)
 	rr := httptest.NewRecorder()
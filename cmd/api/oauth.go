@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"greenlight.bcc/internal/data"
+)
+
+// oauthProvider describes the endpoints and credentials needed to run an
+// authorization-code + PKCE flow against a single third-party provider.
+type oauthProvider struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+	RedirectURL  string
+}
+
+const (
+	oauthStateCookieName = "greenlight_oauth_state"
+	oauthStateCookieTTL  = 10 * time.Minute
+)
+
+// oauthStateCookie is what we store client-side between the login redirect
+// and the callback; it lets the callback validate the state parameter and
+// replay the PKCE verifier without server-side session state. It is stored
+// as base64-encoded JSON and is not signed.
+type oauthStateCookie struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// oauthLoginHandler generates state + a PKCE code verifier/challenge,
+// stashes them in an (unsigned) state cookie, and redirects the browser to the
+// provider's authorize URL. Routed as GET /v1/auth/:provider/login.
+func (app *application) oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	providerName := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	provider, ok := app.config.oauth.providers[providerName]
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	state, err := randomURLSafeString(32)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	codeVerifier, err := randomURLSafeString(64)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setOAuthStateCookie(w, oauthStateCookie{
+		Provider:     providerName,
+		State:        state,
+		CodeVerifier: codeVerifier,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	authorizeURL := buildAuthorizeURL(provider, state, pkceChallenge(codeVerifier))
+
+	http.Redirect(w, r, authorizeURL, http.StatusFound)
+}
+
+// oauthCallbackHandler exchanges the authorization code for tokens, fetches
+// the provider's userinfo, upserts a matching data.User (linked through
+// data.UserIdentities), and returns a session token identical in shape to
+// the one from createAuthenticationTokenHandler. Routed as
+// GET /v1/auth/:provider/callback.
+func (app *application) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	providerName := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	provider, ok := app.config.oauth.providers[providerName]
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	cookie, err := app.readOAuthStateCookie(r)
+	if err != nil || cookie.Provider != providerName || cookie.State != r.URL.Query().Get("state") {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+	app.clearOAuthStateCookie(w)
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		app.badRequestResponse(w, r, errors.New("missing code parameter"))
+		return
+	}
+
+	accessToken, err := app.exchangeOAuthCode(provider, code, cookie.CodeVerifier)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	subject, email, name, err := app.fetchOAuthUserInfo(provider, accessToken)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.resolveOAuthUser(providerName, subject, email, name)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"authentication_token": token}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// resolveOAuthUser looks up the user already linked to (provider, subject);
+// if none exists yet it registers a new, pre-activated user and links it.
+func (app *application) resolveOAuthUser(provider, subject, email, name string) (*data.User, error) {
+	identity, err := app.models.UserIdentities.GetByProviderSubject(provider, subject)
+	if err == nil {
+		return app.models.Users.Get(identity.UserID)
+	}
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user := &data.User{
+		Name:      name,
+		Email:     email,
+		Activated: true,
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.UserIdentities.Insert(&data.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (app *application) exchangeOAuthCode(provider oauthProvider, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.RedirectURL},
+		"client_id":     {provider.ClientID},
+		"client_secret": {provider.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	resp, err := http.PostForm(provider.TokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oauth token exchange failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	return body.AccessToken, nil
+}
+
+func (app *application) fetchOAuthUserInfo(provider oauthProvider, accessToken string) (subject, email, name string, err error) {
+	req, err := http.NewRequest(http.MethodGet, provider.UserInfoURL, nil)
+	if err != nil {
+		return "", "", "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("oauth userinfo request failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Sub   string `json:"sub"`
+		ID    string `json:"id"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", "", "", err
+	}
+
+	subject = body.Sub
+	if subject == "" {
+		subject = body.ID
+	}
+
+	return subject, body.Email, body.Name, nil
+}
+
+func buildAuthorizeURL(provider oauthProvider, state, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {provider.RedirectURL},
+		"scope":                 {joinScopes(provider.Scopes)},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	return provider.AuthURL + "?" + query.Encode()
+}
+
+func joinScopes(scopes []string) string {
+	joined := ""
+	for i, s := range scopes {
+		if i > 0 {
+			joined += " "
+		}
+		joined += s
+	}
+	return joined
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomURLSafeString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (app *application) setOAuthStateCookie(w http.ResponseWriter, state oauthStateCookie) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/",
+		MaxAge:   int(oauthStateCookieTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (app *application) readOAuthStateCookie(r *http.Request) (oauthStateCookie, error) {
+	cookie, err := r.Cookie(oauthStateCookieName)
+	if err != nil {
+		return oauthStateCookie{}, err
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return oauthStateCookie{}, err
+	}
+
+	var state oauthStateCookie
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return oauthStateCookie{}, err
+	}
+
+	return state, nil
+}
+
+func (app *application) clearOAuthStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// newMockOAuthProvider stands up a fake authorization server exposing just
+// enough of the token and userinfo endpoints for exchangeOAuthCode and
+// fetchOAuthUserInfo to exercise against.
+func newMockOAuthProvider(t *testing.T, subject, email, name string) (*httptest.Server, oauthProvider) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "mock-access-token"})
+	})
+
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mock-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"sub":   subject,
+			"email": email,
+			"name":  name,
+		})
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	provider := oauthProvider{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		AuthURL:      ts.URL + "/authorize",
+		TokenURL:     ts.URL + "/token",
+		UserInfoURL:  ts.URL + "/userinfo",
+		Scopes:       []string{"openid", "email", "profile"},
+		RedirectURL:  "http://localhost/v1/auth/mock/callback",
+	}
+
+	return ts, provider
+}
+
+func Test_exchangeOAuthCode(t *testing.T) {
+	app := newTestApplication(t)
+	_, provider := newMockOAuthProvider(t, "subject-123", "user@example.com", "Test User")
+
+	accessToken, err := app.exchangeOAuthCode(provider, "auth-code", "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if accessToken != "mock-access-token" {
+		t.Errorf("expected mock-access-token, got %q", accessToken)
+	}
+}
+
+func Test_fetchOAuthUserInfo(t *testing.T) {
+	app := newTestApplication(t)
+	_, provider := newMockOAuthProvider(t, "subject-123", "user@example.com", "Test User")
+
+	subject, email, name, err := app.fetchOAuthUserInfo(provider, "mock-access-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subject != "subject-123" || email != "user@example.com" || name != "Test User" {
+		t.Errorf("unexpected userinfo: subject=%q email=%q name=%q", subject, email, name)
+	}
+}
+
+func Test_oauthLoginHandler(t *testing.T) {
+	app := newTestApplication(t)
+	_, provider := newMockOAuthProvider(t, "subject-123", "user@example.com", "Test User")
+	app.config.oauth.providers = map[string]oauthProvider{"mock": provider}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/mock/login", nil)
+	req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey,
+		httprouter.Params{{Key: "provider", Value: "mock"}}))
+	rr := httptest.NewRecorder()
+
+	app.oauthLoginHandler(rr, req)
+
+	if rr.Code != http.StatusFound {
+		t.Errorf("expected status %d, got %d", http.StatusFound, rr.Code)
+	}
+
+	location := rr.Header().Get("Location")
+	if location == "" {
+		t.Error("expected a Location header pointing at the provider's authorize URL")
+	}
+
+	if rr.Result().Cookies()[0].Name != oauthStateCookieName {
+		t.Errorf("expected %s cookie to be set", oauthStateCookieName)
+	}
+}
+
+func Test_oauthCallbackHandler_unknownProvider(t *testing.T) {
+	app := newTestApplication(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/bogus/callback", nil)
+	req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey,
+		httprouter.Params{{Key: "provider", Value: "bogus"}}))
+	rr := httptest.NewRecorder()
+
+	app.oauthCallbackHandler(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serve starts the HTTP server and gracefully shuts it down on SIGINT/SIGTERM.
+// When ACME is enabled via config.tls.acme, it delegates to serveACME instead
+// so that certificates are obtained/renewed automatically; otherwise it binds
+// plain HTTP on config.port exactly as before.
+func (app *application) serve() error {
+	if app.config.tls.acme.enabled {
+		return app.serveACME()
+	}
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	return app.runServer(srv, func() error { return srv.ListenAndServe() })
+}
+
+// runServer wires up graceful shutdown on SIGINT/SIGTERM around listenAndServe,
+// which actually starts srv (ListenAndServe, ListenAndServeTLS, ...).
+func (app *application) runServer(srv *http.Server, listenAndServe func() error) error {
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.PrintInfo("shutting down server", map[string]string{"signal": s.String()})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		app.logger.PrintInfo("completing background tasks", map[string]string{"addr": srv.Addr})
+
+		app.wg.Wait()
+		shutdownError <- nil
+	}()
+
+	app.logger.PrintInfo("starting server", map[string]string{"addr": srv.Addr, "env": app.config.env})
+
+	err := listenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("stopped server", map[string]string{"addr": srv.Addr})
+
+	return nil
+}
+
+// httpToHTTPSRedirectHandler permanently redirects plain HTTP requests to
+// the same path over HTTPS, except for ACME HTTP-01 challenge requests
+// which autocert's handler needs to see directly.
+func httpToHTTPSRedirectHandler(acmeHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acmeHandler != nil && strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+			acmeHandler.ServeHTTP(w, r)
+			return
+		}
+
+		target := "https://" + r.Host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// serveACME obtains and renews TLS certificates via ACME/Let's Encrypt for
+// config.tls.acme.hosts, serving the HTTP-01 challenge (and a redirect to
+// HTTPS for everything else) on :80 and the real application on :443.
+func (app *application) serveACME() error {
+	manager := app.config.tls.acme.manager
+
+	httpSrv := &http.Server{
+		Addr:    ":80",
+		Handler: httpToHTTPSRedirectHandler(manager.HTTPHandler(nil)),
+	}
+
+	go func() {
+		err := httpSrv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.logger.PrintError(err, map[string]string{"addr": httpSrv.Addr})
+		}
+	}()
+
+	tlsSrv := &http.Server{
+		Addr:         ":443",
+		Handler:      app.routes(),
+		TLSConfig:    manager.TLSConfig(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	return app.runServer(tlsSrv, func() error { return tlsSrv.ListenAndServeTLS("", "") })
+}
+
+// acmeTLSConfig is a small seam so tests can substitute a stub autocert
+// manager without obtaining real certificates from Let's Encrypt.
+type acmeTLSConfig interface {
+	TLSConfig() *tls.Config
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// newACMEManager builds the autocert.Manager used in production, caching
+// issued certificates under cacheDir and restricting issuance to hosts.
+func newACMEManager(hosts []string, cacheDir string) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+}
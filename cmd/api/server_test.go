@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubACMEManager is a minimal acmeTLSConfig used in place of a real
+// autocert.Manager so the test never talks to Let's Encrypt.
+type stubACMEManager struct {
+	tlsConfig *tls.Config
+}
+
+func (m *stubACMEManager) TLSConfig() *tls.Config {
+	return m.tlsConfig
+}
+
+func (m *stubACMEManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("acme-challenge-response"))
+	})
+}
+
+func Test_httpToHTTPSRedirectHandler(t *testing.T) {
+	t.Run("redirects plain HTTP to HTTPS", func(t *testing.T) {
+		handler := httpToHTTPSRedirectHandler(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/v1/healthcheck", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusPermanentRedirect {
+			t.Errorf("expected status %d, got %d", http.StatusPermanentRedirect, rr.Code)
+		}
+
+		if location := rr.Header().Get("Location"); location != "https://example.com/v1/healthcheck" {
+			t.Errorf("unexpected Location header: got %q", location)
+		}
+	})
+
+	t.Run("delegates ACME challenge requests to the manager", func(t *testing.T) {
+		manager := &stubACMEManager{}
+		handler := httpToHTTPSRedirectHandler(manager.HTTPHandler(nil))
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/acme-challenge/token", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if rr.Body.String() != "acme-challenge-response" {
+			t.Errorf("expected ACME challenge response body, got %q", rr.Body.String())
+		}
+	})
+}
+
+func Test_serveACME_middlewareChainOverTLS(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	client := ts.Client()
+
+	resp, err := client.Get(ts.URL + "/v1/healthcheck")
+	if err != nil {
+		t.Fatalf("request over TLS failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UserIdentity links a third-party OAuth/OIDC identity (provider + subject)
+// to a local greenlight user, so the same account can be reached through
+// more than one provider (e.g. Google and GitHub).
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	CreatedAt time.Time
+}
+
+type UserIdentityModel struct {
+	DB *sql.DB
+}
+
+// GetByProviderSubject looks up the identity that links (provider, subject)
+// to a user; its UserID names that user. It
+// returns ErrRecordNotFound if that identity hasn't been linked to a user
+// yet, which callers treat as "create a new account".
+func (m UserIdentityModel) GetByProviderSubject(provider, subject string) (*UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2`
+
+	var identity UserIdentity
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &identity, nil
+}
+
+// Insert links provider+subject to identity.UserID, so future logins
+// through that provider resolve back to the same greenlight account.
+func (m UserIdentityModel) Insert(identity *UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	args := []interface{}{identity.UserID, identity.Provider, identity.Subject}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&identity.ID, &identity.CreatedAt)
+}